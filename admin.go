@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/jwoglom/consul-port-redirector/routes"
+)
+
+var (
+	adminListen  = flag.String("adminListen", "", "address (e.g. 127.0.0.1:9100) to serve the runtime routes admin API on; disabled when empty")
+	routesDBPath = flag.String("routesDBPath", "routes.db", "path to the SQLite database used to persist runtime-managed custom routes; empty uses an in-memory store")
+)
+
+func newRoutesStore() (routes.Store, error) {
+	if *routesDBPath == "" {
+		return routes.NewMemoryStore(), nil
+	}
+	return routes.NewSQLiteStore(*routesDBPath)
+}
+
+// seedRoutesStore loads the static -customRoutes JSON map into store on
+// first boot, preserving prior behavior for deployments not yet using the
+// admin API.
+func seedRoutesStore(ctx context.Context, store routes.Store, initial map[string]string) error {
+	existing, err := store.List(ctx)
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 || len(initial) == 0 {
+		return nil
+	}
+
+	for hostnamePath, target := range initial {
+		hostname, path := splitHostnamePath(hostnamePath)
+		route := routes.Route{Hostname: hostname, Path: path, Target: target, Mode: routes.ModeRedirect}
+		if err := store.Upsert(ctx, route); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func splitHostnamePath(hostnamePath string) (hostname, path string) {
+	if idx := strings.Index(hostnamePath, "/"); idx >= 0 {
+		return hostnamePath[:idx], hostnamePath[idx:]
+	}
+	return hostnamePath, ""
+}
+
+// buildCustomRoutesMap flattens the store's routes into the hostname(+path)
+// -> Route map consulted by tryCustomRoutesForHostname, keeping each route's
+// Mode and PreserveHost alongside its Target so they take effect when serving.
+func buildCustomRoutesMap(ctx context.Context, store routes.Store) (map[string]routes.Route, error) {
+	all, err := store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	mp := make(map[string]routes.Route, len(all))
+	for _, r := range all {
+		mp[r.Key()] = r
+	}
+	return mp, nil
+}
+
+// watchRoutesStore refreshes the server's in-memory custom routes map
+// whenever the store reports a change, so admin API updates take effect
+// without a restart.
+func (s *Server) watchRoutesStore() {
+	for range s.customRoutesStore.Changes() {
+		mp, err := buildCustomRoutesMap(context.Background(), s.customRoutesStore)
+		if err != nil {
+			log.Printf("routes: failed to refresh custom routes: %#v", err)
+			continue
+		}
+
+		s.customRoutesMu.Lock()
+		s.customRoutes = mp
+		s.customRoutesMu.Unlock()
+	}
+}
+
+// serveAdminAPI starts the runtime admin API (routes CRUD, cache debug dump)
+// on -adminListen, if set. It is served on its own listener so it can be
+// bound to a private interface separate from the main redirector port,
+// since both endpoints expose data that shouldn't be public.
+func serveAdminAPI(s *Server) {
+	if *adminListen == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/-/api/routes", s.handleRoutesAPI)
+	mux.HandleFunc("/-/debug/cache", s.handleCacheDebug)
+
+	go func() {
+		log.Printf("listening on admin port %s", *adminListen)
+		if err := http.ListenAndServe(*adminListen, mux); err != nil {
+			log.Printf("admin listener stopped: %#v", err)
+		}
+	}()
+}
+
+func (s *Server) handleRoutesAPI(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	switch req.Method {
+	case http.MethodGet:
+		all, err := s.customRoutesStore.List(ctx)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(res, all)
+
+	case http.MethodPost:
+		var route routes.Route
+		if err := json.NewDecoder(req.Body).Decode(&route); err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.customRoutesStore.Upsert(ctx, route); err != nil {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(res, route)
+
+	case http.MethodDelete:
+		hostname := req.URL.Query().Get("hostname")
+		path := req.URL.Query().Get("path")
+		if err := s.customRoutesStore.Delete(ctx, hostname, path); err != nil {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		res.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(res, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(res http.ResponseWriter, v interface{}) {
+	res.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(res).Encode(v)
+}
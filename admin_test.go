@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jwoglom/consul-port-redirector/routes"
+)
+
+func newTestAdminServer() *Server {
+	return &Server{customRoutesStore: routes.NewMemoryStore()}
+}
+
+func Test_handleRoutesAPI_postGetDelete(t *testing.T) {
+	s := newTestAdminServer()
+
+	body, _ := json.Marshal(routes.Route{Hostname: "foo.consul", Path: "/api", Target: "http://10.0.0.1:8080", Mode: routes.ModeProxy})
+	postReq := httptest.NewRequest(http.MethodPost, "/-/api/routes", bytes.NewReader(body))
+	postRec := httptest.NewRecorder()
+	s.handleRoutesAPI(postRec, postReq)
+
+	if postRec.Code != http.StatusOK {
+		t.Fatalf("POST: expected 200, got %d: %s", postRec.Code, postRec.Body.String())
+	}
+	var posted routes.Route
+	if err := json.Unmarshal(postRec.Body.Bytes(), &posted); err != nil {
+		t.Fatalf("POST: decoding response: %v", err)
+	}
+	if posted.Target != "http://10.0.0.1:8080" || posted.Mode != routes.ModeProxy {
+		t.Errorf("POST: unexpected echoed route: %#v", posted)
+	}
+
+	getReq := httptest.NewRequest(http.MethodGet, "/-/api/routes", nil)
+	getRec := httptest.NewRecorder()
+	s.handleRoutesAPI(getRec, getReq)
+
+	if getRec.Code != http.StatusOK {
+		t.Fatalf("GET: expected 200, got %d: %s", getRec.Code, getRec.Body.String())
+	}
+	var all []routes.Route
+	if err := json.Unmarshal(getRec.Body.Bytes(), &all); err != nil {
+		t.Fatalf("GET: decoding response: %v", err)
+	}
+	if len(all) != 1 || all[0].Target != "http://10.0.0.1:8080" {
+		t.Errorf("GET: expected 1 route with target http://10.0.0.1:8080, got %#v", all)
+	}
+
+	delReq := httptest.NewRequest(http.MethodDelete, "/-/api/routes?hostname=foo.consul&path=/api", nil)
+	delRec := httptest.NewRecorder()
+	s.handleRoutesAPI(delRec, delReq)
+
+	if delRec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE: expected 204, got %d: %s", delRec.Code, delRec.Body.String())
+	}
+
+	getRec2 := httptest.NewRecorder()
+	s.handleRoutesAPI(getRec2, httptest.NewRequest(http.MethodGet, "/-/api/routes", nil))
+	all = nil
+	if err := json.Unmarshal(getRec2.Body.Bytes(), &all); err != nil {
+		t.Fatalf("GET after DELETE: decoding response: %v", err)
+	}
+	if len(all) != 0 {
+		t.Errorf("GET after DELETE: expected no routes, got %#v", all)
+	}
+}
+
+func Test_handleRoutesAPI_postAcceptsArbitraryMode(t *testing.T) {
+	s := newTestAdminServer()
+
+	// the handler does not validate Mode against the known constants; an
+	// unrecognized value is stored as-is and, since routeTo proxies only for
+	// routes.ModeProxy, is served as a redirect whatever the -mode flag says.
+	body, _ := json.Marshal(routes.Route{Hostname: "bogus.consul", Target: "http://10.0.0.1:9090", Mode: routes.Mode("not-a-real-mode")})
+	req := httptest.NewRequest(http.MethodPost, "/-/api/routes", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	s.handleRoutesAPI(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for an unrecognized Mode, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	all, err := s.customRoutesStore.List(req.Context())
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(all) != 1 || all[0].Mode != "not-a-real-mode" {
+		t.Errorf("expected the unrecognized Mode to be stored unchanged, got %#v", all)
+	}
+}
+
+func Test_handleRoutesAPI_methodNotAllowed(t *testing.T) {
+	s := newTestAdminServer()
+
+	req := httptest.NewRequest(http.MethodPut, "/-/api/routes", nil)
+	rec := httptest.NewRecorder()
+	s.handleRoutesAPI(rec, req)
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for PUT, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,264 @@
+// Package catalog maintains a live view of Consul service health using
+// blocking queries, so the request path becomes a pure in-memory map
+// lookup instead of a synchronous call to Consul on every request.
+package catalog
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// Entry is a single healthy (or, with includeWarning, warning) service
+// instance.
+type Entry struct {
+	Node string
+	Tags []string
+	Port uint16
+}
+
+// Key identifies a (service name, service type) pair to watch.
+type Key struct {
+	Name string
+	Type string
+}
+
+// MetricsRecorder receives observations from a Cache's background
+// watchers, letting callers wire up their own instrumentation without this
+// package depending on it directly.
+type MetricsRecorder interface {
+	ObserveQueryDuration(d time.Duration)
+	ObserveServiceHealth(service, status string)
+}
+
+type noopRecorder struct{}
+
+func (noopRecorder) ObserveQueryDuration(time.Duration)  {}
+func (noopRecorder) ObserveServiceHealth(string, string) {}
+
+type watcher struct {
+	entries []Entry
+	ready   chan struct{}
+	once    sync.Once
+
+	lastSeen time.Time
+	cancel   context.CancelFunc
+}
+
+// Cache maintains one blocking-query watcher goroutine per (service, type)
+// pair that has been looked up, serving subsequent lookups from memory.
+type Cache struct {
+	consul         *api.Client
+	recorder       MetricsRecorder
+	includeWarning bool
+	maxEntries     int
+	idleTTL        time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.RWMutex
+	watchers map[Key]*watcher
+}
+
+// NewCache builds a Cache and starts its background idle-eviction loop.
+// recorder may be nil to skip metrics.
+func NewCache(consul *api.Client, recorder MetricsRecorder, includeWarning bool, maxEntries int, idleTTL time.Duration) *Cache {
+	if recorder == nil {
+		recorder = noopRecorder{}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c := &Cache{
+		consul:         consul,
+		recorder:       recorder,
+		includeWarning: includeWarning,
+		maxEntries:     maxEntries,
+		idleTTL:        idleTTL,
+		ctx:            ctx,
+		cancel:         cancel,
+		watchers:       make(map[Key]*watcher),
+	}
+
+	go c.evictLoop()
+
+	return c
+}
+
+// Stop cancels all watcher goroutines.
+func (c *Cache) Stop() {
+	c.cancel()
+}
+
+// Get returns cached entries for key, spawning its watcher goroutine on
+// first access. The first lookup for a key blocks, bounded by ctx, until
+// the initial fill completes; subsequent lookups are a pure map read.
+func (c *Cache) Get(ctx context.Context, key Key) ([]Entry, error) {
+	w := c.watcherFor(key)
+
+	select {
+	case <-w.ready:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.ctx.Done():
+		return nil, c.ctx.Err()
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return w.entries, nil
+}
+
+func (c *Cache) watcherFor(key Key) *watcher {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	w, ok := c.watchers[key]
+	if !ok {
+		watchCtx, cancel := context.WithCancel(c.ctx)
+		w = &watcher{ready: make(chan struct{}), cancel: cancel}
+		c.watchers[key] = w
+		go c.watch(watchCtx, key, w)
+	}
+	w.lastSeen = time.Now()
+
+	return w
+}
+
+// watch runs Consul blocking queries for key until ctx is canceled,
+// updating w.entries whenever the result changes.
+func (c *Cache) watch(ctx context.Context, key Key, w *watcher) {
+	var lastIndex uint64
+
+	for ctx.Err() == nil {
+		start := time.Now()
+		entries, meta, err := c.consul.Health().Service(key.Name, key.Type, false, (&api.QueryOptions{
+			WaitIndex: lastIndex,
+			WaitTime:  5 * time.Minute,
+		}).WithContext(ctx))
+		c.recorder.ObserveQueryDuration(time.Since(start))
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("catalog: error watching %s/%s: %#v", key.Name, key.Type, err)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+
+		filtered := make([]Entry, 0, len(entries))
+		for _, entry := range entries {
+			status := entry.Checks.AggregatedStatus()
+			c.recorder.ObserveServiceHealth(key.Name, status)
+
+			if status != api.HealthPassing && !(c.includeWarning && status == api.HealthWarning) {
+				continue
+			}
+
+			filtered = append(filtered, Entry{
+				Node: entry.Node.Node,
+				Tags: entry.Service.Tags,
+				Port: uint16(entry.Service.Port),
+			})
+		}
+
+		sort.Slice(filtered, func(i, j int) bool {
+			if filtered[i].Node != filtered[j].Node {
+				return filtered[i].Node < filtered[j].Node
+			}
+			return filtered[i].Port < filtered[j].Port
+		})
+
+		c.mu.Lock()
+		w.entries = filtered
+		c.mu.Unlock()
+
+		w.once.Do(func() { close(w.ready) })
+
+		lastIndex = meta.LastIndex
+	}
+}
+
+// evictLoop periodically drops watchers idle longer than idleTTL, and, if
+// -cacheMaxEntries is exceeded, the least-recently-used watchers beyond it,
+// so the watcher goroutine count stays bounded.
+func (c *Cache) evictLoop() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			c.evict()
+		}
+	}
+}
+
+func (c *Cache) evict() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for key, w := range c.watchers {
+		if c.idleTTL > 0 && now.Sub(w.lastSeen) > c.idleTTL {
+			w.cancel()
+			delete(c.watchers, key)
+		}
+	}
+
+	if c.maxEntries <= 0 || len(c.watchers) <= c.maxEntries {
+		return
+	}
+
+	type keyed struct {
+		key      Key
+		lastSeen time.Time
+	}
+	ordered := make([]keyed, 0, len(c.watchers))
+	for key, w := range c.watchers {
+		ordered = append(ordered, keyed{key, w.lastSeen})
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		return ordered[i].lastSeen.Before(ordered[j].lastSeen)
+	})
+
+	for _, k := range ordered[:len(ordered)-c.maxEntries] {
+		c.watchers[k.key].cancel()
+		delete(c.watchers, k.key)
+	}
+}
+
+// NumWatchers returns the number of (service, type) pairs currently cached.
+func (c *Cache) NumWatchers() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.watchers)
+}
+
+// Dump returns a JSON-serializable snapshot of the cache for the
+// /-/debug/cache endpoint.
+func (c *Cache) Dump() map[string]interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	keys := make(map[string]interface{}, len(c.watchers))
+	for key, w := range c.watchers {
+		keys[key.Name+"/"+key.Type] = map[string]interface{}{
+			"entries":  w.entries,
+			"lastSeen": w.lastSeen,
+		}
+	}
+
+	return map[string]interface{}{
+		"watchers": keys,
+		"count":    len(c.watchers),
+	}
+}
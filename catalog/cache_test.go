@@ -0,0 +1,66 @@
+package catalog
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func newTestCache(maxEntries int, idleTTL time.Duration) *Cache {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Cache{
+		recorder:   noopRecorder{},
+		maxEntries: maxEntries,
+		idleTTL:    idleTTL,
+		ctx:        ctx,
+		cancel:     cancel,
+		watchers:   make(map[Key]*watcher),
+	}
+}
+
+func addTestWatcher(c *Cache, name string, lastSeen time.Time) {
+	_, cancel := context.WithCancel(c.ctx)
+	c.watchers[Key{Name: name}] = &watcher{lastSeen: lastSeen, cancel: cancel}
+}
+
+func Test_Cache_evict_idleTTL(t *testing.T) {
+	c := newTestCache(0, time.Minute)
+	addTestWatcher(c, "stale", time.Now().Add(-2*time.Minute))
+	addTestWatcher(c, "fresh", time.Now())
+
+	c.evict()
+
+	if c.NumWatchers() != 1 {
+		t.Fatalf("expected 1 watcher to survive eviction, got %d", c.NumWatchers())
+	}
+	if _, ok := c.watchers[Key{Name: "fresh"}]; !ok {
+		t.Errorf("expected the fresh watcher to survive, watchers: %#v", c.watchers)
+	}
+}
+
+func Test_Cache_evict_maxEntriesLRU(t *testing.T) {
+	c := newTestCache(2, 0)
+	addTestWatcher(c, "oldest", time.Now().Add(-3*time.Minute))
+	addTestWatcher(c, "middle", time.Now().Add(-2*time.Minute))
+	addTestWatcher(c, "newest", time.Now().Add(-1*time.Minute))
+
+	c.evict()
+
+	if c.NumWatchers() != 2 {
+		t.Fatalf("expected 2 watchers to survive eviction, got %d", c.NumWatchers())
+	}
+	if _, ok := c.watchers[Key{Name: "oldest"}]; ok {
+		t.Errorf("expected the least-recently-used watcher to be evicted")
+	}
+}
+
+func Test_Cache_evict_noEviction(t *testing.T) {
+	c := newTestCache(0, 0)
+	addTestWatcher(c, "a", time.Now().Add(-time.Hour))
+
+	c.evict()
+
+	if c.NumWatchers() != 1 {
+		t.Errorf("expected no eviction when idleTTL and maxEntries are both disabled, got %d watchers", c.NumWatchers())
+	}
+}
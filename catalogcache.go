@@ -0,0 +1,35 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"time"
+)
+
+var (
+	cacheMaxEntries = flag.Int("cacheMaxEntries", 256, "maximum number of (service, type) watchers kept in the catalog cache")
+	cacheIdleTTL    = flag.Duration("cacheIdleTTL", 30*time.Minute, "how long a (service, type) watcher may go unused before its cache entry is evicted")
+)
+
+// catalogMetricsAdapter feeds catalog.Cache observations into the server's
+// Prometheus metrics without the catalog package depending on it directly.
+type catalogMetricsAdapter struct {
+	metrics *metrics
+}
+
+func (a catalogMetricsAdapter) ObserveQueryDuration(d time.Duration) {
+	a.metrics.consulQueryDuration.Observe(d.Seconds())
+}
+
+func (a catalogMetricsAdapter) ObserveServiceHealth(service, status string) {
+	a.metrics.consulServiceHealth.WithLabelValues(service, status).Set(1)
+}
+
+// handleCacheDebug serves a JSON dump of the catalog cache's current
+// watchers at /-/debug/cache, served on the admin listener alongside the
+// routes API since it also exposes internal catalog data.
+func (s *Server) handleCacheDebug(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(res).Encode(s.catalogCache.Dump())
+}
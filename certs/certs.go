@@ -0,0 +1,243 @@
+// Package certs provides TLS certificate sourcing for the redirector's
+// HTTPS listener: either loading real certificates from a directory
+// (refreshed on change) or minting them on the fly from an in-memory
+// self-signed development CA.
+package certs
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Manager implements tls.Config.GetCertificate, selecting a certificate by
+// the SNI hostname the client requested.
+type Manager struct {
+	mu    sync.RWMutex
+	certs map[string]*tls.Certificate // keyed by CN and each SAN
+
+	// selfSigned, when set, mints leaf certificates on demand from this CA
+	// for hostnames not already cached.
+	selfSigned *devCA
+}
+
+// NewDirManager loads *.crt/*.key pairs from certDir/keyDir (matched by
+// file name stem) and watches both directories for changes, reloading
+// affected certificates as files are added, modified, or removed.
+func NewDirManager(certDir, keyDir string) (*Manager, error) {
+	m := &Manager{certs: make(map[string]*tls.Certificate)}
+
+	if err := m.loadDir(certDir, keyDir); err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(certDir); err != nil {
+		return nil, err
+	}
+	if keyDir != certDir {
+		if err := watcher.Add(keyDir); err != nil {
+			return nil, err
+		}
+	}
+
+	go m.watch(watcher, certDir, keyDir)
+
+	return m, nil
+}
+
+// NewSelfSignedManager generates an in-memory CA and returns a Manager that
+// mints leaf certificates on demand for whatever hostname a client's
+// ClientHello requests. The CA's PEM-encoded certificate is returned so the
+// caller can print it for developers to trust locally.
+func NewSelfSignedManager() (m *Manager, caPEM []byte, err error) {
+	ca, caPEM, err := newDevCA()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &Manager{
+		certs:      make(map[string]*tls.Certificate),
+		selfSigned: ca,
+	}, caPEM, nil
+}
+
+// GetCertificate is installed as tls.Config.GetCertificate.
+func (m *Manager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	name := hello.ServerName
+	if name == "" {
+		return nil, fmt.Errorf("certs: no SNI server name in ClientHello")
+	}
+
+	m.mu.RLock()
+	cert, ok := m.certs[name]
+	m.mu.RUnlock()
+	if ok {
+		return cert, nil
+	}
+
+	if m.selfSigned == nil {
+		return nil, fmt.Errorf("certs: no certificate available for %s", name)
+	}
+
+	cert, err := m.selfSigned.mint(name)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	m.certs[name] = cert
+	m.mu.Unlock()
+
+	return cert, nil
+}
+
+func (m *Manager) loadDir(certDir, keyDir string) error {
+	certFiles, err := filepath.Glob(filepath.Join(certDir, "*.crt"))
+	if err != nil {
+		return err
+	}
+
+	loaded := make(map[string]*tls.Certificate)
+	for _, certFile := range certFiles {
+		stem := strings.TrimSuffix(filepath.Base(certFile), ".crt")
+		keyFile := filepath.Join(keyDir, stem+".key")
+
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			log.Printf("certs: skipping %s: %#v", certFile, err)
+			continue
+		}
+
+		for _, name := range certNames(cert) {
+			loaded[name] = &cert
+		}
+	}
+
+	m.mu.Lock()
+	m.certs = loaded
+	m.mu.Unlock()
+
+	return nil
+}
+
+func certNames(cert tls.Certificate) []string {
+	var names []string
+	if len(cert.Certificate) == 0 {
+		return names
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return names
+	}
+
+	if leaf.Subject.CommonName != "" {
+		names = append(names, leaf.Subject.CommonName)
+	}
+	names = append(names, leaf.DNSNames...)
+
+	return names
+}
+
+func (m *Manager) watch(watcher *fsnotify.Watcher, certDir, keyDir string) {
+	defer watcher.Close()
+
+	for event := range watcher.Events {
+		if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) == 0 {
+			continue
+		}
+
+		log.Printf("certs: reloading after change to %s", event.Name)
+		if err := m.loadDir(certDir, keyDir); err != nil {
+			log.Printf("certs: reload failed: %#v", err)
+		}
+	}
+}
+
+// devCA is an in-memory certificate authority used to mint leaf
+// certificates for local development when no real certificates are
+// configured.
+type devCA struct {
+	cert *x509.Certificate
+	key  *rsa.PrivateKey
+
+	mu     sync.Mutex
+	serial *big.Int
+}
+
+func newDevCA() (*devCA, []byte, error) {
+	key, err := rsaGenerateKey()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               caSubject(),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(10 * 365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, publicKey(key), key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &devCA{cert: cert, key: key, serial: big.NewInt(1)}, pemEncodeCert(der), nil
+}
+
+// mint issues a leaf certificate for host, signed by the CA, with a fresh
+// serial number.
+func (ca *devCA) mint(host string) (*tls.Certificate, error) {
+	key, err := rsaGenerateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	ca.mu.Lock()
+	ca.serial.Add(ca.serial, big.NewInt(1))
+	serial := new(big.Int).Set(ca.serial)
+	ca.mu.Unlock()
+
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      leafSubject(host),
+		DNSNames:     []string{host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, publicKey(key), ca.key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Certificate{
+		Certificate: [][]byte{der, ca.cert.Raw},
+		PrivateKey:  key,
+	}, nil
+}
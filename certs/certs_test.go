@@ -0,0 +1,43 @@
+package certs
+
+import (
+	"crypto/x509"
+	"testing"
+)
+
+func Test_devCA_mint(t *testing.T) {
+	ca, _, err := newDevCA()
+	if err != nil {
+		t.Fatalf("newDevCA: %v", err)
+	}
+
+	cert, err := ca.mint("foo.example.com")
+	if err != nil {
+		t.Fatalf("mint: %v", err)
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("parsing minted leaf: %v", err)
+	}
+
+	if len(leaf.DNSNames) != 1 || leaf.DNSNames[0] != "foo.example.com" {
+		t.Errorf("expected DNSNames [foo.example.com], got %#v", leaf.DNSNames)
+	}
+
+	if err := leaf.CheckSignatureFrom(ca.cert); err != nil {
+		t.Errorf("expected leaf to be signed by the dev CA: %v", err)
+	}
+
+	second, err := ca.mint("foo.example.com")
+	if err != nil {
+		t.Fatalf("mint: %v", err)
+	}
+	secondLeaf, err := x509.ParseCertificate(second.Certificate[0])
+	if err != nil {
+		t.Fatalf("parsing second minted leaf: %v", err)
+	}
+	if leaf.SerialNumber.Cmp(secondLeaf.SerialNumber) == 0 {
+		t.Errorf("expected distinct serial numbers across mints")
+	}
+}
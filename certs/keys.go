@@ -0,0 +1,31 @@
+package certs
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509/pkix"
+	"encoding/pem"
+)
+
+func rsaGenerateKey() (*rsa.PrivateKey, error) {
+	return rsa.GenerateKey(rand.Reader, 2048)
+}
+
+func publicKey(key *rsa.PrivateKey) *rsa.PublicKey {
+	return &key.PublicKey
+}
+
+func caSubject() pkix.Name {
+	return pkix.Name{
+		CommonName:   "consul-port-redirector dev CA",
+		Organization: []string{"consul-port-redirector"},
+	}
+}
+
+func leafSubject(host string) pkix.Name {
+	return pkix.Name{CommonName: host}
+}
+
+func pemEncodeCert(der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
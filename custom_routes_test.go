@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jwoglom/consul-port-redirector/routes"
+)
+
+func Test_redirectToCustomRoute_perRouteModeOverridesGlobalFlag(t *testing.T) {
+	var gotHost string
+	backend := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		gotHost = req.Host
+		res.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	// global -mode defaults to "redirect"; the route below requests "proxy"
+	// and PreserveHost, which must take effect regardless.
+	s := &Server{proxy: newProxySet()}
+
+	route := routes.Route{
+		Hostname:     "custom.example.com",
+		Target:       backend.URL,
+		Mode:         routes.ModeProxy,
+		PreserveHost: true,
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Host = "custom.example.com"
+	rec := httptest.NewRecorder()
+
+	if err := s.redirectToCustomRoute(rec, req, "custom.example.com", route); err != nil {
+		t.Fatalf("redirectToCustomRoute: %v", err)
+	}
+
+	if rec.Code == http.StatusTemporaryRedirect {
+		t.Fatalf("expected route.Mode=proxy to reverse-proxy instead of redirecting")
+	}
+	if gotHost != "custom.example.com" {
+		t.Errorf("expected PreserveHost to forward the original Host header, got %q", gotHost)
+	}
+}
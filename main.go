@@ -8,10 +8,13 @@ import (
 	"log"
 	"net/http"
 	"net/url"
-	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/hashicorp/consul/api"
+	"github.com/jwoglom/consul-port-redirector/catalog"
+	"github.com/jwoglom/consul-port-redirector/routes"
 )
 
 var (
@@ -38,7 +41,14 @@ func runServer() error {
 		return err
 	}
 
-	http.Handle("/", s)
+	serveAdminAPI(s)
+
+	plainHandler, err := maybeServeTLS(s)
+	if err != nil {
+		return err
+	}
+
+	http.Handle("/", plainHandler)
 	log.Printf("listening on port :%d", *port)
 	return http.ListenAndServe(fmt.Sprintf(":%d", *port), nil)
 }
@@ -46,8 +56,16 @@ func runServer() error {
 // Server implements a http.Handler to serve HTTP requests
 // with a redirect to the correct port of the Consul service
 type Server struct {
-	consul       *api.Client
-	customRoutes map[string]string
+	consul *api.Client
+
+	customRoutesMu    sync.RWMutex
+	customRoutes      map[string]routes.Route
+	customRoutesStore routes.Store
+
+	tagRouter    *tagRouter
+	proxy        *proxySet
+	metrics      *metrics
+	catalogCache *catalog.Cache
 }
 
 func NewServer() (*Server, error) {
@@ -61,14 +79,73 @@ func NewServer() (*Server, error) {
 		return nil, err
 	}
 
-	if len(parsedCustomRoutes) > 0 {
-		log.Printf("Found custom routes: %#v\n", parsedCustomRoutes)
+	store, err := newRoutesStore()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := seedRoutesStore(context.Background(), store, parsedCustomRoutes); err != nil {
+		return nil, err
+	}
+
+	customRoutesMap, err := buildCustomRoutesMap(context.Background(), store)
+	if err != nil {
+		return nil, err
 	}
 
-	return &Server{
-		consul:       client,
-		customRoutes: parsedCustomRoutes,
-	}, nil
+	if len(customRoutesMap) > 0 {
+		log.Printf("Found custom routes: %#v\n", customRoutesMap)
+	}
+
+	router := newTagRouter(client, *tagRoutingPrefix)
+	router.start(context.Background(), *tagRefreshInterval)
+
+	m := newMetrics()
+	cache := catalog.NewCache(client, catalogMetricsAdapter{m}, *includeWarning, *cacheMaxEntries, *cacheIdleTTL)
+
+	s := &Server{
+		consul:            client,
+		customRoutes:      customRoutesMap,
+		customRoutesStore: store,
+		tagRouter:         router,
+		proxy:             newProxySet(),
+		metrics:           m,
+		catalogCache:      cache,
+	}
+
+	go s.watchRoutesStore()
+	go s.watchCacheSize()
+
+	return s, nil
+}
+
+// watchCacheSize periodically reports the catalog cache's watcher count on
+// the consul_services_cached gauge.
+func (s *Server) watchCacheSize() {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.metrics.consulServicesCached.Set(float64(s.catalogCache.NumWatchers()))
+	}
+}
+
+// redirectOrProxy sends the client to u, either via a 307 redirect or, when
+// running with -mode=proxy, by reverse-proxying the request to u directly.
+func (s *Server) redirectOrProxy(res http.ResponseWriter, req *http.Request, u *url.URL, insecure bool) {
+	s.routeTo(res, req, u, insecure, routes.Mode(*mode), false)
+}
+
+// routeTo sends the client to u according to routeMode ("redirect" issues a
+// 307, "proxy" reverse-proxies the request to u directly), optionally
+// preserving the original inbound Host header when proxying.
+func (s *Server) routeTo(res http.ResponseWriter, req *http.Request, u *url.URL, insecure bool, routeMode routes.Mode, preserveHost bool) {
+	if routeMode == routes.ModeProxy {
+		s.proxy.proxyTo(res, req, u, insecure, preserveHost)
+		return
+	}
+
+	http.Redirect(res, req, u.String(), http.StatusTemporaryRedirect)
 }
 
 func parseCustomRoutes(raw string) (map[string]string, error) {
@@ -93,19 +170,33 @@ func (s *Server) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	// No prometheus metrics (yet)
 	if strings.HasPrefix(strings.TrimPrefix(req.URL.Path, "/"), "metrics") {
-		res.WriteHeader(200)
+		s.metrics.handler().ServeHTTP(res, req)
 		return
 	}
 
+	start := time.Now()
 	hostname := getHostname(req)
 	log.Printf("request: %s%s", req.Host, req.URL.Path)
+
+	outcome := "error"
+	defer func() {
+		s.metrics.observeRequest(hostname, outcome, time.Since(start))
+	}()
+
+	if route, ok := s.tagRouter.match(hostname, req.URL.Path); ok {
+		s.redirectToTagRoute(res, req, route)
+		outcome = resultForMode()
+		return
+	}
+
 	if s.tryCustomRoutesForHostname(res, req, hostname) {
+		outcome = "custom"
 		return
 	} else if strings.HasSuffix(hostname, fmt.Sprintf(".%s", *hostnameSuffix)) {
 		cutHostname := strings.TrimSuffix(hostname, fmt.Sprintf(".%s", *hostnameSuffix))
 		if s.tryCustomRoutesForHostname(res, req, cutHostname) {
+			outcome = "custom"
 			return
 		}
 	}
@@ -130,6 +221,7 @@ func (s *Server) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 		}
 
 		http.Redirect(res, req, redirUrl.String(), http.StatusTemporaryRedirect)
+		outcome = "redirect"
 		return
 	}
 
@@ -144,6 +236,7 @@ func (s *Server) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 
 		s.printHostnameTips(res)
 		s.printQuickLinks(res, hostname)
+		outcome = "not_found"
 		return
 	}
 
@@ -175,7 +268,8 @@ func (s *Server) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 
 		log.Printf("redirecting to %s", u.String())
 
-		http.Redirect(res, req, u.String(), http.StatusTemporaryRedirect)
+		s.redirectOrProxy(res, req, u, false)
+		outcome = resultForMode()
 		return
 	}
 
@@ -194,6 +288,7 @@ func (s *Server) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 
 		s.printHostnameTips(res)
 		s.printQuickLinks(res, hostname)
+		outcome = "not_found"
 		return
 	}
 
@@ -226,6 +321,16 @@ func (s *Server) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 
 	_, _ = res.Write([]byte("</ul><br />"))
 	s.printQuickLinks(res, hostname)
+	outcome = "not_found"
+}
+
+// resultForMode reports the requests_total result label to use when a
+// RedirectOption was successfully resolved, depending on -mode.
+func resultForMode() string {
+	if *mode == modeProxy {
+		return "proxy"
+	}
+	return "redirect"
 }
 
 func (s *Server) printHostnameTips(res http.ResponseWriter) {
@@ -284,8 +389,12 @@ func (s *Server) tryCustomRoutesForHostname(res http.ResponseWriter, req *http.R
 }
 
 func (s *Server) tryRedirectRoutePath(res http.ResponseWriter, req *http.Request, hostnamePath string) bool {
-	if redirUrl, ok := s.customRoutes[hostnamePath]; ok {
-		err := redirectToCustomRoute(res, req, hostnamePath, redirUrl)
+	s.customRoutesMu.RLock()
+	route, ok := s.customRoutes[hostnamePath]
+	s.customRoutesMu.RUnlock()
+
+	if ok {
+		err := s.redirectToCustomRoute(res, req, hostnamePath, route)
 		if err != nil {
 			log.Printf("error processing custom route with %s: %#v", hostnamePath, err)
 
@@ -300,13 +409,16 @@ func (s *Server) tryRedirectRoutePath(res http.ResponseWriter, req *http.Request
 	return false
 }
 
-func redirectToCustomRoute(res http.ResponseWriter, req *http.Request, hostname, customUrl string) error {
+func (s *Server) redirectToCustomRoute(res http.ResponseWriter, req *http.Request, hostname string, route routes.Route) error {
+	customUrl := route.Target
 	parsedUrl, err := url.Parse(customUrl)
 	if err != nil {
 		return err
 	}
 
-	redirUrl, err := buildUrlWithPort(parsedUrl.Host, req.URL, parsedUrl.Scheme, 0)
+	scheme, insecure := expandProxyArg(parsedUrl.Scheme)
+
+	redirUrl, err := buildUrlWithPort(parsedUrl.Host, req.URL, scheme, 0)
 	if err != nil {
 		return err
 	}
@@ -325,10 +437,41 @@ func redirectToCustomRoute(res http.ResponseWriter, req *http.Request, hostname,
 		redirUrl.Path = parsedUrl.Path + redirUrl.Path
 	}
 
-	http.Redirect(res, req, redirUrl.String(), http.StatusTemporaryRedirect)
+	routeMode := route.Mode
+	if routeMode == "" {
+		routeMode = routes.Mode(*mode)
+	}
+	s.routeTo(res, req, redirUrl, insecure, routeMode, route.PreserveHost)
 	return nil
 }
 
+// redirectToTagRoute redirects a request matched against the tag-derived
+// routing table to its target service, applying the route's scheme and
+// strip options.
+func (s *Server) redirectToTagRoute(res http.ResponseWriter, req *http.Request, route tagRoute) {
+	u, err := route.Target.BuildURL(route.Target.Hostname, req.URL)
+	if err != nil {
+		log.Printf("error building URL for tag route %s: %#v", route.Host, err)
+		res.Header().Set("Content-Type", "text/html")
+		res.WriteHeader(http.StatusInternalServerError)
+		_, _ = res.Write([]byte(fmt.Sprintf(`
+<p>Error building URL for tag route %s: %#v</p>
+		`, route.Host, err)))
+		return
+	}
+
+	scheme, insecure := expandProxyArg(route.Scheme)
+	if scheme != "" {
+		u.Scheme = scheme
+	}
+	if route.Strip != "" {
+		u.Path = strings.TrimPrefix(u.Path, route.Strip)
+	}
+
+	log.Printf("redirecting to tag route %s", u.String())
+	s.redirectOrProxy(res, req, u, insecure)
+}
+
 func addHostnameSuffix(hostname string) string {
 	if len(*hostnameSuffix) == 0 {
 		return hostname
@@ -377,6 +520,10 @@ func (r *RedirectOption) guessScheme() string {
 	return "http"
 }
 
+// queryConsulForHostname resolves a hostname to its Consul service
+// instances via the catalog cache, spawning a blocking-query watcher on
+// first request for that (service, type) pair and serving a pure map
+// lookup thereafter.
 func (s *Server) queryConsulForHostname(ctx context.Context, hostname string) ([]RedirectOption, error) {
 	var options []RedirectOption
 
@@ -385,27 +532,23 @@ func (s *Server) queryConsulForHostname(ctx context.Context, hostname string) ([
 		return options, nil
 	}
 
-	services, _, err := s.consul.Catalog().Service(svcName, svcType, &api.QueryOptions{})
+	fillCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	entries, err := s.catalogCache.Get(fillCtx, catalog.Key{Name: svcName, Type: svcType})
 	if err != nil {
 		return options, err
 	}
 
-	log.Printf("found %d options for hostname %s:", len(services), hostname)
-	for _, svc := range services {
-		log.Printf("%s port %d: %#v", svc.Address, svc.ServicePort, *svc)
-
+	log.Printf("found %d options for hostname %s:", len(entries), hostname)
+	for _, entry := range entries {
 		options = append(options, RedirectOption{
-			Hostname: svc.Node,
-			Tags:     svc.ServiceTags,
-			Port:     uint16(svc.ServicePort),
+			Hostname: entry.Node,
+			Tags:     entry.Tags,
+			Port:     entry.Port,
 		})
 	}
 
-	// sort lowest -> highest port number for each hostname
-	sort.Slice(options, func(i, j int) bool {
-		return options[i].Hostname < options[j].Hostname && options[i].Port < options[j].Port
-	})
-
 	return options, nil
 }
 
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var includeWarning = flag.Bool("includeWarning", false, "if true, treat Consul services in \"warning\" health status as usable in addition to \"passing\"")
+
+// metrics holds the Prometheus collectors exposed on /metrics.
+type metrics struct {
+	registry *prometheus.Registry
+
+	requestsTotal        *prometheus.CounterVec
+	requestDuration      *prometheus.HistogramVec
+	consulQueryDuration  prometheus.Histogram
+	consulServicesCached prometheus.Gauge
+	consulServiceHealth  *prometheus.GaugeVec
+}
+
+func newMetrics() *metrics {
+	registry := prometheus.NewRegistry()
+
+	m := &metrics{
+		registry: registry,
+		requestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "requests_total",
+			Help: "Total requests handled, labeled by host and result.",
+		}, []string{"host", "result"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "request_duration_seconds",
+			Help: "Time spent handling a request, labeled by host.",
+		}, []string{"host"}),
+		consulQueryDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name: "consul_query_duration_seconds",
+			Help: "Time spent querying Consul for service health.",
+		}),
+		consulServicesCached: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "consul_services_cached",
+			Help: "Number of (service, type) pairs currently watched by the Consul catalog cache.",
+		}),
+		consulServiceHealth: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "consul_service_health",
+			Help: "Health status observed for a Consul service, labeled by service and status.",
+		}, []string{"service", "status"}),
+	}
+
+	registry.MustRegister(
+		m.requestsTotal,
+		m.requestDuration,
+		m.consulQueryDuration,
+		m.consulServicesCached,
+		m.consulServiceHealth,
+	)
+
+	return m
+}
+
+// handler serves the registry in the standard Prometheus exposition format.
+func (m *metrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func (m *metrics) observeRequest(host, result string, duration time.Duration) {
+	m.requestsTotal.WithLabelValues(host, result).Inc()
+	m.requestDuration.WithLabelValues(host).Observe(duration.Seconds())
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_metrics_observeRequest(t *testing.T) {
+	m := newMetrics()
+	m.observeRequest("foo.example.com", "redirect", 25*time.Millisecond)
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	rec := httptest.NewRecorder()
+	m.handler().ServeHTTP(rec, req)
+
+	body := rec.Body.String()
+	want := `requests_total{host="foo.example.com",result="redirect"} 1`
+	if !strings.Contains(body, want) {
+		t.Errorf("expected /metrics output to contain %q, got:\n%s", want, body)
+	}
+}
+
+func Test_metrics_consulServicesCached(t *testing.T) {
+	m := newMetrics()
+	m.consulServicesCached.Set(3)
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	rec := httptest.NewRecorder()
+	m.handler().ServeHTTP(rec, req)
+
+	if !strings.Contains(rec.Body.String(), "consul_services_cached 3") {
+		t.Errorf("expected /metrics output to report consul_services_cached 3, got:\n%s", rec.Body.String())
+	}
+}
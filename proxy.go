@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	mode               = flag.String("mode", "redirect", "how to route matched requests: \"redirect\" (307) or \"proxy\" (reverse proxy)")
+	trustXFF           = flag.Bool("trustXFF", false, "if true, keep an existing X-Forwarded-For header and append the client address to it, instead of replacing it with the client address")
+	proxyIdleConnsHost = flag.Int("proxyMaxIdleConnsPerHost", 8, "max idle connections kept per backend when in proxy mode")
+	proxyMaxConnsHost  = flag.Int("proxyMaxConnsPerHost", 64, "max concurrent connections allowed per backend when in proxy mode")
+	proxyDialTimeout   = flag.Duration("proxyDialTimeout", 10*time.Second, "dial timeout for backend connections when in proxy mode")
+)
+
+const modeProxy = "proxy"
+
+// requestPathKey is the context key under which proxyTo stashes the
+// resolved path+query for the current request, since the director on a
+// cached *httputil.ReverseProxy can't otherwise tell a per-request resolved
+// path (e.g. after a tag route's strip= or a custom route's path rewrite)
+// from the raw inbound request path.
+type requestPathKey struct{}
+
+// requestPath is the resolved path+query to forward to the backend.
+type requestPath struct {
+	path     string
+	rawQuery string
+}
+
+// proxySet lazily builds and caches one *httputil.ReverseProxy per
+// backend, so repeated requests to the same backend reuse a transport
+// (and its connection pool) rather than dialing fresh every time.
+type proxySet struct {
+	mu    sync.Mutex
+	byKey map[string]*httputil.ReverseProxy
+}
+
+func newProxySet() *proxySet {
+	return &proxySet{byKey: make(map[string]*httputil.ReverseProxy)}
+}
+
+// proxyTo reverse-proxies req to targetUrl, reusing a cached proxy/transport
+// for that backend. insecure marks the backend as using "https+insecure"
+// semantics, skipping TLS verification for that backend's transport only.
+// preserveHost forwards the request with its original inbound Host header
+// instead of rewriting it to targetUrl's host.
+func (p *proxySet) proxyTo(res http.ResponseWriter, req *http.Request, targetUrl *url.URL, insecure, preserveHost bool) {
+	key := targetUrl.Scheme + "://" + targetUrl.Host
+	if insecure {
+		key += "+insecure"
+	}
+	if preserveHost {
+		key += "+preservehost"
+	}
+
+	p.mu.Lock()
+	rp, ok := p.byKey[key]
+	if !ok {
+		rp = newReverseProxy(targetUrl, insecure, preserveHost)
+		p.byKey[key] = rp
+	}
+	p.mu.Unlock()
+
+	ctx := context.WithValue(req.Context(), requestPathKey{}, requestPath{path: targetUrl.Path, rawQuery: targetUrl.RawQuery})
+	rp.ServeHTTP(res, req.WithContext(ctx))
+}
+
+// newReverseProxy builds a proxy for the given scheme+host. Unlike
+// httputil.NewSingleHostReverseProxy, the director never joins a frozen
+// target path onto the request: target is only used for scheme/host, which
+// is invariant for a given cache key (see proxyTo); the per-request path
+// (which may differ from the inbound request's, e.g. after a strip= tag
+// option or a custom route rewrite) is threaded through via request context
+// since it can change on every call to a proxy cached across requests.
+func newReverseProxy(target *url.URL, insecure, preserveHost bool) *httputil.ReverseProxy {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout: *proxyDialTimeout,
+		}).DialContext,
+		MaxIdleConnsPerHost: *proxyIdleConnsHost,
+		MaxConnsPerHost:     *proxyMaxConnsHost,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	if insecure {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	rp := &httputil.ReverseProxy{
+		Transport: transport,
+		Director: func(req *http.Request) {
+			clientHost := req.Host
+
+			req.URL.Scheme = target.Scheme
+			req.URL.Host = target.Host
+			if rp, ok := req.Context().Value(requestPathKey{}).(requestPath); ok {
+				req.URL.Path = rp.path
+				req.URL.RawQuery = rp.rawQuery
+			}
+			if !preserveHost {
+				req.Host = target.Host
+			}
+			req.Header.Set("X-Forwarded-Host", clientHost)
+			req.Header.Set("X-Forwarded-Proto", forwardedProto(req))
+
+			if *trustXFF && req.Header.Get("X-Forwarded-For") != "" {
+				return
+			}
+			// ReverseProxy appends the client address after the director
+			// runs, so clearing the header leaves only the client address.
+			req.Header.Del("X-Forwarded-For")
+		},
+	}
+
+	return rp
+}
+
+func forwardedProto(req *http.Request) string {
+	if req.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// expandProxyArg parses a target string that may use the "https+insecure"
+// scheme (as tailscale does) to mean "https, but skip certificate
+// verification for this backend", returning the plain scheme to dial with
+// plus whether verification should be skipped.
+func expandProxyArg(scheme string) (plainScheme string, insecure bool) {
+	if strings.HasPrefix(scheme, "https+insecure") {
+		return "https", true
+	}
+	return scheme, false
+}
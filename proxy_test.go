@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func Test_proxySet_proxyTo_doesNotLeakPathAcrossRequests(t *testing.T) {
+	var gotPaths []string
+	backend := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		gotPaths = append(gotPaths, req.URL.Path)
+		res.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	backendURL, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatalf("parsing backend URL: %v", err)
+	}
+
+	set := newProxySet()
+
+	first := httptest.NewRequest(http.MethodGet, "/foo/bar", nil)
+	firstTarget := *backendURL
+	firstTarget.Path = "/foo/bar"
+	set.proxyTo(httptest.NewRecorder(), first, &firstTarget, false, false)
+
+	second := httptest.NewRequest(http.MethodGet, "/baz", nil)
+	secondTarget := *backendURL
+	secondTarget.Path = "/baz"
+	set.proxyTo(httptest.NewRecorder(), second, &secondTarget, false, false)
+
+	if len(gotPaths) != 2 {
+		t.Fatalf("expected 2 requests to reach the backend, got %d: %#v", len(gotPaths), gotPaths)
+	}
+	if gotPaths[0] != "/foo/bar" {
+		t.Errorf("expected first request path /foo/bar, got %s", gotPaths[0])
+	}
+	if gotPaths[1] != "/baz" {
+		t.Errorf("expected second request to a cached proxy to keep its own path /baz, got %s", gotPaths[1])
+	}
+}
+
+func Test_proxySet_proxyTo_forwardsResolvedPath(t *testing.T) {
+	var gotPath, gotQuery string
+	backend := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		gotPath = req.URL.Path
+		gotQuery = req.URL.RawQuery
+		res.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	backendURL, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatalf("parsing backend URL: %v", err)
+	}
+
+	set := newProxySet()
+
+	// the inbound request is for "/api/widgets?x=1", but the resolved
+	// target (e.g. after a strip= tag option or custom route rewrite)
+	// points at "/widgets?y=2" -- the backend should see the resolved
+	// path and query, not the original inbound ones.
+	req := httptest.NewRequest(http.MethodGet, "/api/widgets?x=1", nil)
+	target := *backendURL
+	target.Path = "/widgets"
+	target.RawQuery = "y=2"
+	set.proxyTo(httptest.NewRecorder(), req, &target, false, false)
+
+	if gotPath != "/widgets" {
+		t.Errorf("expected the backend to receive the resolved path /widgets, got %s", gotPath)
+	}
+	if gotQuery != "y=2" {
+		t.Errorf("expected the backend to receive the resolved query y=2, got %s", gotQuery)
+	}
+}
@@ -0,0 +1,61 @@
+package routes
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryStore is an in-memory Store. It is not persisted across restarts;
+// it exists for tests and for running without a SQLite database.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	routes  map[string]Route
+	changes chan struct{}
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		routes:  make(map[string]Route),
+		changes: make(chan struct{}, 1),
+	}
+}
+
+func (m *MemoryStore) List(ctx context.Context) ([]Route, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	out := make([]Route, 0, len(m.routes))
+	for _, r := range m.routes {
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) Upsert(ctx context.Context, route Route) error {
+	m.mu.Lock()
+	m.routes[route.Key()] = route
+	m.mu.Unlock()
+
+	m.notify()
+	return nil
+}
+
+func (m *MemoryStore) Delete(ctx context.Context, hostname, path string) error {
+	m.mu.Lock()
+	delete(m.routes, hostname+path)
+	m.mu.Unlock()
+
+	m.notify()
+	return nil
+}
+
+func (m *MemoryStore) Changes() <-chan struct{} {
+	return m.changes
+}
+
+func (m *MemoryStore) notify() {
+	select {
+	case m.changes <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,42 @@
+package routes
+
+import (
+	"context"
+	"testing"
+)
+
+func Test_MemoryStore_UpsertListDelete(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	route := Route{Hostname: "foo.consul", Path: "/api", Target: "http://10.0.0.1:8080", Mode: ModeRedirect}
+	if err := store.Upsert(ctx, route); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+
+	all, err := store.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(all) != 1 || all[0].Target != route.Target {
+		t.Errorf("expected 1 route with target %s, got %#v", route.Target, all)
+	}
+
+	select {
+	case <-store.Changes():
+	default:
+		t.Errorf("expected a change notification after Upsert")
+	}
+
+	if err := store.Delete(ctx, route.Hostname, route.Path); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	all, err = store.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(all) != 0 {
+		t.Errorf("expected no routes after Delete, got %#v", all)
+	}
+}
@@ -0,0 +1,111 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteStore is the default Store, persisting routes to a SQLite database
+// so they survive restarts.
+type SQLiteStore struct {
+	db      *sql.DB
+	changes chan struct{}
+}
+
+const createTableSQL = `
+CREATE TABLE IF NOT EXISTS routes (
+	hostname      TEXT NOT NULL,
+	path          TEXT NOT NULL,
+	target        TEXT NOT NULL,
+	mode          TEXT NOT NULL,
+	preserve_host INTEGER NOT NULL,
+	updated_at    TEXT NOT NULL,
+	PRIMARY KEY (hostname, path)
+)`
+
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := db.Exec(createTableSQL); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteStore{db: db, changes: make(chan struct{}, 1)}, nil
+}
+
+func (s *SQLiteStore) List(ctx context.Context) ([]Route, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT hostname, path, target, mode, preserve_host, updated_at FROM routes`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Route
+	for rows.Next() {
+		var r Route
+		var preserveHost int
+		var updatedAt string
+		if err := rows.Scan(&r.Hostname, &r.Path, &r.Target, &r.Mode, &preserveHost, &updatedAt); err != nil {
+			return nil, err
+		}
+		r.PreserveHost = preserveHost != 0
+		r.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+func (s *SQLiteStore) Upsert(ctx context.Context, route Route) error {
+	route.UpdatedAt = time.Now()
+
+	_, err := s.db.ExecContext(ctx, `
+INSERT INTO routes (hostname, path, target, mode, preserve_host, updated_at)
+VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT(hostname, path) DO UPDATE SET
+	target = excluded.target,
+	mode = excluded.mode,
+	preserve_host = excluded.preserve_host,
+	updated_at = excluded.updated_at
+`, route.Hostname, route.Path, route.Target, route.Mode, boolToInt(route.PreserveHost), route.UpdatedAt.Format(time.RFC3339))
+	if err != nil {
+		return err
+	}
+
+	s.notify()
+	return nil
+}
+
+func (s *SQLiteStore) Delete(ctx context.Context, hostname, path string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM routes WHERE hostname = ? AND path = ?`, hostname, path)
+	if err != nil {
+		return err
+	}
+
+	s.notify()
+	return nil
+}
+
+func (s *SQLiteStore) Changes() <-chan struct{} {
+	return s.changes
+}
+
+func (s *SQLiteStore) notify() {
+	select {
+	case s.changes <- struct{}{}:
+	default:
+	}
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
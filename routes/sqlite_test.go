@@ -0,0 +1,87 @@
+package routes
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func Test_SQLiteStore_UpsertListDelete(t *testing.T) {
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "routes.db")
+
+	store, err := NewSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+
+	route := Route{Hostname: "foo.consul", Path: "/api", Target: "http://10.0.0.1:8080", Mode: ModeProxy, PreserveHost: true}
+	if err := store.Upsert(ctx, route); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+
+	all, err := store.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(all) != 1 || all[0].Target != route.Target || all[0].Mode != ModeProxy || !all[0].PreserveHost {
+		t.Errorf("expected 1 route matching %#v, got %#v", route, all)
+	}
+
+	select {
+	case <-store.Changes():
+	default:
+		t.Errorf("expected a change notification after Upsert")
+	}
+
+	// upserting the same (hostname, path) again should replace, not duplicate
+	route.Target = "http://10.0.0.1:9090"
+	if err := store.Upsert(ctx, route); err != nil {
+		t.Fatalf("Upsert (replace): %v", err)
+	}
+	all, err = store.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(all) != 1 || all[0].Target != "http://10.0.0.1:9090" {
+		t.Errorf("expected the upsert to replace the existing route, got %#v", all)
+	}
+
+	if err := store.Delete(ctx, route.Hostname, route.Path); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	all, err = store.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(all) != 0 {
+		t.Errorf("expected no routes after Delete, got %#v", all)
+	}
+}
+
+func Test_SQLiteStore_persistsAcrossInstances(t *testing.T) {
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "routes.db")
+
+	first, err := NewSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	route := Route{Hostname: "foo.consul", Target: "http://10.0.0.1:8080", Mode: ModeRedirect}
+	if err := first.Upsert(ctx, route); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+
+	second, err := NewSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatalf("reopening NewSQLiteStore: %v", err)
+	}
+	all, err := second.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(all) != 1 || all[0].Target != route.Target {
+		t.Errorf("expected the route to persist across store instances, got %#v", all)
+	}
+}
@@ -0,0 +1,58 @@
+// Package routes persists the custom hostname routing table used by the
+// redirector, so routes can be managed at runtime via the admin API instead
+// of only through the static -customRoutes flag.
+package routes
+
+import (
+	"context"
+	"time"
+)
+
+// Mode selects how a matched route is applied.
+type Mode string
+
+const (
+	ModeRedirect Mode = "redirect"
+	ModeProxy    Mode = "proxy"
+)
+
+// Route is a single custom routing rule: requests to Hostname (optionally
+// restricted to requests under Path) are sent to Target.
+type Route struct {
+	Hostname string
+	Path     string
+	Target   string
+
+	// Mode overrides the global -mode flag for this route: "redirect"
+	// issues a 307 to Target, "proxy" reverse-proxies the request to it.
+	// Empty falls back to the global -mode flag.
+	Mode Mode
+
+	// PreserveHost, when routing in proxy mode, forwards the request to
+	// Target with the original inbound Host header instead of rewriting
+	// it to Target's host.
+	PreserveHost bool
+
+	UpdatedAt time.Time
+}
+
+// Key uniquely identifies a Route within a Store.
+func (r Route) Key() string {
+	return r.Hostname + r.Path
+}
+
+// Store persists Routes and notifies subscribers when they change.
+type Store interface {
+	// List returns all routes currently in the store.
+	List(ctx context.Context) ([]Route, error)
+
+	// Upsert creates or replaces the route at (Hostname, Path).
+	Upsert(ctx context.Context, route Route) error
+
+	// Delete removes the route at (hostname, path), if any.
+	Delete(ctx context.Context, hostname, path string) error
+
+	// Changes receives a value whenever the store's contents change, so
+	// callers can refresh a cached view without restarting.
+	Changes() <-chan struct{}
+}
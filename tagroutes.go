@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+var (
+	tagRoutingPrefix   = flag.String("tagRoutePrefix", "urlprefix-", "Consul service tag prefix used to discover fabio-style url routes")
+	tagRefreshInterval = flag.Duration("tagRouteRefreshInterval", 30*time.Second, "how often to rescan the Consul catalog for tag-based routes")
+)
+
+// tagRoute is a single parsed "urlprefix-" tag, along with the service
+// instance it was found on.
+type tagRoute struct {
+	Host   string
+	Path   string
+	Target RedirectOption
+	Scheme string
+	Strip  string
+	Weight float64
+}
+
+// tagRouter maintains a routing table built from scanning the Consul
+// catalog for services tagged with urlprefix-style tags, refreshing it
+// on a timer so routes stay current without a restart.
+type tagRouter struct {
+	consul *api.Client
+	prefix string
+
+	mu    sync.RWMutex
+	table map[string][]tagRoute // host -> routes, sorted by longest path prefix first
+
+	rrMu sync.Mutex
+	rr   map[string]int // host+path -> next round-robin index
+}
+
+func newTagRouter(consul *api.Client, prefix string) *tagRouter {
+	return &tagRouter{
+		consul: consul,
+		prefix: prefix,
+		table:  make(map[string][]tagRoute),
+		rr:     make(map[string]int),
+	}
+}
+
+// start performs an initial scan and then refreshes the routing table
+// every interval until ctx is canceled.
+func (t *tagRouter) start(ctx context.Context, interval time.Duration) {
+	if err := t.refresh(); err != nil {
+		log.Printf("tagRouter: initial scan failed: %#v", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := t.refresh(); err != nil {
+					log.Printf("tagRouter: refresh failed: %#v", err)
+				}
+			}
+		}
+	}()
+}
+
+// refresh walks the Consul catalog and rebuilds the routing table from
+// any urlprefix- tags found on registered services.
+func (t *tagRouter) refresh() error {
+	services, _, err := t.consul.Catalog().Services(&api.QueryOptions{})
+	if err != nil {
+		return err
+	}
+
+	table := make(map[string][]tagRoute)
+
+	for svcName, tags := range services {
+		hasPrefix := false
+		for _, tag := range tags {
+			if strings.HasPrefix(tag, t.prefix) {
+				hasPrefix = true
+				break
+			}
+		}
+		if !hasPrefix {
+			continue
+		}
+
+		instances, _, err := t.consul.Catalog().Service(svcName, "", &api.QueryOptions{})
+		if err != nil {
+			log.Printf("tagRouter: error fetching service %s: %#v", svcName, err)
+			continue
+		}
+
+		for _, inst := range instances {
+			addr := inst.ServiceAddress
+			if addr == "" {
+				addr = inst.Address
+			}
+
+			target := RedirectOption{
+				Hostname: addr,
+				Tags:     inst.ServiceTags,
+				Port:     uint16(inst.ServicePort),
+			}
+
+			for _, tag := range inst.ServiceTags {
+				if !strings.HasPrefix(tag, t.prefix) {
+					continue
+				}
+
+				route, ok := parseTagRoute(strings.TrimPrefix(tag, t.prefix), target)
+				if !ok {
+					log.Printf("tagRouter: could not parse tag %q on service %s", tag, svcName)
+					continue
+				}
+
+				table[route.Host] = append(table[route.Host], route)
+			}
+		}
+	}
+
+	for host := range table {
+		// longest path prefix first so lookups can take the first match
+		sort.SliceStable(table[host], func(i, j int) bool {
+			return len(table[host][i].Path) > len(table[host][j].Path)
+		})
+	}
+
+	t.mu.Lock()
+	t.table = table
+	t.mu.Unlock()
+
+	return nil
+}
+
+// parseTagRoute parses the portion of a tag following the configured
+// prefix: "<host>[/path][ opts...]" e.g. "foo.example.com/api strip=/api proto=https".
+func parseTagRoute(raw string, target RedirectOption) (tagRoute, bool) {
+	fields := strings.Fields(raw)
+	if len(fields) == 0 {
+		return tagRoute{}, false
+	}
+
+	hostPath := fields[0]
+	host := hostPath
+	path := ""
+	if idx := strings.Index(hostPath, "/"); idx >= 0 {
+		host = hostPath[:idx]
+		path = hostPath[idx:]
+	}
+	if host == "" {
+		return tagRoute{}, false
+	}
+
+	route := tagRoute{
+		Host:   host,
+		Path:   path,
+		Target: target,
+		Scheme: target.guessScheme(),
+		Weight: 1,
+	}
+
+	for _, opt := range fields[1:] {
+		kv := strings.SplitN(opt, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "proto":
+			route.Scheme = kv[1]
+		case "strip":
+			route.Strip = kv[1]
+		case "weight":
+			if w, err := strconv.ParseFloat(kv[1], 64); err == nil {
+				route.Weight = w
+			}
+		}
+	}
+
+	return route, true
+}
+
+// match returns the tag route for the given host and request path, if any,
+// matching the longest registered path prefix. When more than one service
+// registers the same (host, path) pair, selection is weighted via
+// "weight=" tag options, falling back to round-robin among equal weights.
+func (t *tagRouter) match(host, path string) (tagRoute, bool) {
+	// "*.example.com" wildcard tags match any subdomain of example.com
+	wildcardHost := ""
+	if idx := strings.Index(host, "."); idx >= 0 {
+		wildcardHost = "*" + host[idx:]
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	for _, candidateHost := range []string{host, wildcardHost} {
+		if candidateHost == "" {
+			continue
+		}
+		routes, ok := t.table[candidateHost]
+		if !ok {
+			continue
+		}
+
+		for _, route := range routes {
+			if route.Path == "" || strings.HasPrefix(path, route.Path) {
+				group := t.groupMatching(routes, route.Path)
+				return t.pick(candidateHost, route.Path, group), true
+			}
+		}
+	}
+
+	return tagRoute{}, false
+}
+
+func (t *tagRouter) groupMatching(routes []tagRoute, path string) []tagRoute {
+	var group []tagRoute
+	for _, r := range routes {
+		if r.Path == path {
+			group = append(group, r)
+		}
+	}
+	return group
+}
+
+// pick selects a route from a group of equally-matched routes, choosing at
+// random in proportion to weight= when the weights differ and falling back
+// to round-robin when they are all equal.
+func (t *tagRouter) pick(host, path string, group []tagRoute) tagRoute {
+	if len(group) == 1 {
+		return group[0]
+	}
+
+	totalWeight := 0.0
+	equalWeights := true
+	for _, r := range group {
+		totalWeight += r.Weight
+		if r.Weight != group[0].Weight {
+			equalWeights = false
+		}
+	}
+
+	if totalWeight > 0 && !equalWeights {
+		target := rand.Float64() * totalWeight
+		for _, r := range group {
+			target -= r.Weight
+			if target <= 0 {
+				return r
+			}
+		}
+		return group[len(group)-1]
+	}
+
+	key := host + path
+	t.rrMu.Lock()
+	idx := t.rr[key] % len(group)
+	t.rr[key] = idx + 1
+	t.rrMu.Unlock()
+
+	return group[idx]
+}
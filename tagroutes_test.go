@@ -0,0 +1,89 @@
+package main
+
+import "testing"
+
+func Test_parseTagRoute(t *testing.T) {
+	target := RedirectOption{Hostname: "10.0.0.1", Port: 8080}
+
+	route, ok := parseTagRoute("foo.example.com/api strip=/api proto=https weight=2", target)
+	if !ok {
+		t.Fatalf("expected parseTagRoute to succeed")
+	}
+	if route.Host != "foo.example.com" || route.Path != "/api" || route.Strip != "/api" || route.Scheme != "https" || route.Weight != 2 {
+		t.Errorf("unexpected route: %#v", route)
+	}
+
+	route, ok = parseTagRoute("foo.example.com", target)
+	if !ok || route.Host != "foo.example.com" || route.Path != "" || route.Weight != 1 {
+		t.Errorf("unexpected route for bare host: %#v", route)
+	}
+
+	if _, ok := parseTagRoute("", target); ok {
+		t.Errorf("expected empty tag to fail to parse")
+	}
+
+	if _, ok := parseTagRoute("/onlypath", target); ok {
+		t.Errorf("expected a tag with no host to fail to parse")
+	}
+}
+
+func Test_tagRouter_match_longestPrefix(t *testing.T) {
+	router := newTagRouter(nil, "urlprefix-")
+	router.table = map[string][]tagRoute{
+		"foo.example.com": {
+			{Host: "foo.example.com", Path: "/api/v2", Weight: 1},
+			{Host: "foo.example.com", Path: "/api", Weight: 1},
+			{Host: "foo.example.com", Path: "", Weight: 1},
+		},
+	}
+
+	route, ok := router.match("foo.example.com", "/api/v2/widgets")
+	if !ok || route.Path != "/api/v2" {
+		t.Errorf("expected longest-prefix match /api/v2, got %#v ok=%v", route, ok)
+	}
+
+	route, ok = router.match("foo.example.com", "/api/other")
+	if !ok || route.Path != "/api" {
+		t.Errorf("expected prefix match /api, got %#v ok=%v", route, ok)
+	}
+
+	route, ok = router.match("foo.example.com", "/unrelated")
+	if !ok || route.Path != "" {
+		t.Errorf("expected fallback to bare host route, got %#v ok=%v", route, ok)
+	}
+
+	if _, ok := router.match("bar.example.com", "/"); ok {
+		t.Errorf("expected no match for unregistered host")
+	}
+}
+
+func Test_tagRouter_match_wildcardHost(t *testing.T) {
+	router := newTagRouter(nil, "urlprefix-")
+	router.table = map[string][]tagRoute{
+		"*.example.com": {{Host: "*.example.com", Path: "", Weight: 1}},
+	}
+
+	route, ok := router.match("anything.example.com", "/")
+	if !ok || route.Host != "*.example.com" {
+		t.Errorf("expected wildcard match, got %#v ok=%v", route, ok)
+	}
+}
+
+func Test_tagRouter_pick_roundRobin(t *testing.T) {
+	router := newTagRouter(nil, "urlprefix-")
+	group := []tagRoute{
+		{Host: "foo.example.com", Path: "", Weight: 1, Target: RedirectOption{Hostname: "a"}},
+		{Host: "foo.example.com", Path: "", Weight: 1, Target: RedirectOption{Hostname: "b"}},
+	}
+
+	first := router.pick("foo.example.com", "", group)
+	second := router.pick("foo.example.com", "", group)
+	third := router.pick("foo.example.com", "", group)
+
+	if first.Target.Hostname == second.Target.Hostname {
+		t.Errorf("expected round-robin to alternate, got %s then %s", first.Target.Hostname, second.Target.Hostname)
+	}
+	if third.Target.Hostname != first.Target.Hostname {
+		t.Errorf("expected round-robin to cycle back to the first entry, got %s", third.Target.Hostname)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/jwoglom/consul-port-redirector/certs"
+)
+
+var (
+	tlsPort             = flag.Uint("tlsPort", 0, "https port; if nonzero, also serve the handler over TLS")
+	tlsCertDir          = flag.String("tlsCertDir", "", "directory of *.crt files to serve over TLS, keyed by CN/SAN")
+	tlsKeyDir           = flag.String("tlsKeyDir", "", "directory of *.key files matching -tlsCertDir by file name stem (defaults to -tlsCertDir)")
+	selfSigned          = flag.Bool("selfSigned", false, "if true and no -tlsCertDir is set, mint certificates on the fly from an in-memory self-signed dev CA")
+	redirectHTTPToHTTPS = flag.Bool("redirectHTTPToHTTPS", false, "if true, the plain HTTP listener issues 308 redirects to the HTTPS listener instead of serving requests")
+)
+
+// maybeServeTLS starts an HTTPS listener alongside the plain listener when
+// -tlsPort is set, selecting a certificate per-request via SNI. It returns a
+// handler to install on the plain HTTP listener: either the original
+// handler, or one that redirects to HTTPS when -redirectHTTPToHTTPS is set.
+func maybeServeTLS(handler http.Handler) (http.Handler, error) {
+	if *tlsPort == 0 {
+		return handler, nil
+	}
+
+	manager, err := newCertManager()
+	if err != nil {
+		return nil, err
+	}
+
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", *tlsPort),
+		Handler: handler,
+		TLSConfig: &tls.Config{
+			GetCertificate: manager.GetCertificate,
+		},
+	}
+
+	go func() {
+		log.Printf("listening on tls port :%d", *tlsPort)
+		if err := server.ListenAndServeTLS("", ""); err != nil {
+			log.Printf("tls listener stopped: %#v", err)
+		}
+	}()
+
+	if !*redirectHTTPToHTTPS {
+		return handler, nil
+	}
+
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		u := *req.URL
+		u.Scheme = "https"
+		u.Host = fmt.Sprintf("%s:%d", getHostname(req), *tlsPort)
+		http.Redirect(res, req, u.String(), http.StatusPermanentRedirect)
+	}), nil
+}
+
+func newCertManager() (*certs.Manager, error) {
+	if *tlsCertDir != "" {
+		keyDir := *tlsKeyDir
+		if keyDir == "" {
+			keyDir = *tlsCertDir
+		}
+		return certs.NewDirManager(*tlsCertDir, keyDir)
+	}
+
+	if !*selfSigned {
+		return nil, fmt.Errorf("tlsserver: -tlsPort set without -tlsCertDir or -selfSigned")
+	}
+
+	manager, caPEM, err := certs.NewSelfSignedManager()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("self-signed dev CA (trust this to avoid browser warnings):\n%s", caPEM)
+	return manager, nil
+}